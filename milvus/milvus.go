@@ -4,7 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"math"
 	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-aie/gptbot"
 	"github.com/go-aie/xslices"
@@ -13,9 +20,76 @@ import (
 )
 
 const (
-	idCol, titleCol, headingCol, contentCol, embeddingCol = "id", "title", "heading", "content", "embedding"
+	idCol, titleCol, headingCol, contentCol, embeddingCol, sparseEmbeddingCol = "id", "title", "heading", "content", "embedding", "sparse_embedding"
 )
 
+// SparseEmbedding is a sparse vector represented as a map from term position
+// to weight, e.g. the output of a SPLADE-style encoder or a lexical scorer.
+type SparseEmbedding map[uint32]float32
+
+// Document bundles a gptbot.Section together with store-specific data that
+// gptbot.Section itself has no room for, such as a sparse embedding.
+type Document struct {
+	gptbot.Section
+
+	// SparseEmbedding is the sparse counterpart of Section.Embedding.
+	// It is optional: a zero-value (nil) SparseEmbedding is inserted as an
+	// empty sparse vector and simply never contributes to HybridQuery.
+	SparseEmbedding SparseEmbedding
+
+	// Metadata is arbitrary user data (e.g. tenant ID, source URL) stored in
+	// the collection's dynamic field and echoed back in Similarity. It
+	// enables per-tenant or per-source retrieval via Query's Filter without
+	// having to add a dedicated schema column.
+	Metadata map[string]any
+
+	// ID is the stable primary key for this document. If zero and Key is
+	// set, ID is derived by hashing Key. A Document without either is only
+	// safe to Insert, never to Upsert: Insert falls back to assigning the
+	// slice index, which is not stable across re-ingests.
+	ID int64
+
+	// Key, when set, is hashed into ID so callers can address documents by a
+	// natural identifier (e.g. a URL or a file path) instead of managing
+	// int64 primary keys themselves.
+	Key string
+}
+
+// resolveID returns doc's primary key: ID if set, else Key hashed to an
+// int64, else fallback.
+func (doc Document) resolveID(fallback int64) int64 {
+	if doc.ID != 0 {
+		return doc.ID
+	}
+	if doc.Key != "" {
+		return hashKey(doc.Key)
+	}
+	return fallback
+}
+
+// hashKey deterministically maps key to an int64 primary key using FNV-64a.
+func hashKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+// Similarity extends gptbot.Similarity with the Metadata attached at
+// insertion time.
+type Similarity struct {
+	gptbot.Similarity
+	Metadata map[string]any
+
+	// DenseScore and SparseScore are the raw, per-modality scores HybridQuery
+	// produced before fusion, so callers can inspect each modality's
+	// contribution. A document absent from a modality's result list gets zero
+	// for that modality. Query leaves both at zero.
+	DenseScore  float64
+	SparseScore float64
+}
+
+const metaCol = "$meta"
+
 type Config struct {
 	// CollectionName is the collection name.
 	// This field is required.
@@ -28,6 +102,14 @@ type Config struct {
 	// Dim is the embedding dimension.
 	// Defaults to 1536 (the dimension generated by OpenAI's Embedding API).
 	Dim int
+
+	// Index configures the index built on the dense embedding column.
+	// Defaults to IVF_FLAT with L2, nlist=128.
+	Index IndexConfig
+
+	// Search configures the search parameters used by Query.
+	// Defaults to the parameters matching Index's type.
+	Search SearchConfig
 }
 
 func (cfg *Config) init() {
@@ -37,6 +119,123 @@ func (cfg *Config) init() {
 	if cfg.Dim == 0 {
 		cfg.Dim = 1536
 	}
+	cfg.Index.init()
+	cfg.Search.init()
+}
+
+// IndexType identifies a Milvus index algorithm for the dense embedding
+// column.
+type IndexType string
+
+const (
+	IndexTypeIvfFlat   IndexType = "IVF_FLAT"
+	IndexTypeIvfSQ8    IndexType = "IVF_SQ8"
+	IndexTypeHNSW      IndexType = "HNSW"
+	IndexTypeDiskANN   IndexType = "DISKANN"
+	IndexTypeAutoIndex IndexType = "AUTOINDEX"
+)
+
+// IndexConfig selects the index type, metric, and index-specific build
+// parameters used for the dense embedding column.
+//
+// OpenAI embeddings are L2-normalized, so COSINE or IP typically give better
+// recall than the default L2.
+type IndexConfig struct {
+	// Type is the index algorithm. Defaults to IndexTypeIvfFlat.
+	Type IndexType
+
+	// Metric is the distance metric. Defaults to entity.L2.
+	Metric entity.MetricType
+
+	// Nlist is the number of cluster units, used by IVF_FLAT and IVF_SQ8.
+	// Defaults to 128.
+	Nlist int
+
+	// M is the number of edges per node in the HNSW graph, used by HNSW.
+	// Defaults to 16.
+	M int
+
+	// EfConstruction controls the HNSW build-time search scope, used by
+	// HNSW. Defaults to 64.
+	EfConstruction int
+}
+
+func (idx *IndexConfig) init() {
+	if idx.Type == "" {
+		idx.Type = IndexTypeIvfFlat
+	}
+	if idx.Metric == "" {
+		idx.Metric = entity.L2
+	}
+	if idx.Nlist == 0 {
+		idx.Nlist = 128
+	}
+	if idx.M == 0 {
+		idx.M = 16
+	}
+	if idx.EfConstruction == 0 {
+		idx.EfConstruction = 64
+	}
+}
+
+// buildIndex constructs the entity.Index matching idx's Type.
+func (idx IndexConfig) buildIndex() (entity.Index, error) {
+	switch idx.Type {
+	case IndexTypeIvfFlat:
+		return entity.NewIndexIvfFlat(idx.Metric, idx.Nlist)
+	case IndexTypeIvfSQ8:
+		return entity.NewIndexIvfSQ8(idx.Metric, idx.Nlist)
+	case IndexTypeHNSW:
+		return entity.NewIndexHNSW(idx.Metric, idx.M, idx.EfConstruction)
+	case IndexTypeDiskANN:
+		return entity.NewIndexDISKANN(idx.Metric)
+	case IndexTypeAutoIndex:
+		return entity.NewIndexAUTOINDEX(idx.Metric)
+	default:
+		return nil, fmt.Errorf("milvus: unsupported index type %q", idx.Type)
+	}
+}
+
+// SearchConfig provides the search-time parameters matching IndexConfig's
+// Type, e.g. nprobe for IVF_FLAT/IVF_SQ8 or ef for HNSW.
+type SearchConfig struct {
+	// Nprobe is the number of units to query, used by IVF_FLAT and IVF_SQ8.
+	// Defaults to 10.
+	Nprobe int
+
+	// Ef is the search scope, used by HNSW. Defaults to 64.
+	Ef int
+
+	// SearchK is the search scope, used by DISKANN. Defaults to 100.
+	SearchK int
+}
+
+func (s *SearchConfig) init() {
+	if s.Nprobe == 0 {
+		s.Nprobe = 10
+	}
+	if s.Ef == 0 {
+		s.Ef = 64
+	}
+	if s.SearchK == 0 {
+		s.SearchK = 100
+	}
+}
+
+// buildSearchParam constructs the entity.SearchParam matching idxType.
+func (s SearchConfig) buildSearchParam(idxType IndexType) (entity.SearchParam, error) {
+	switch idxType {
+	case IndexTypeIvfFlat, IndexTypeIvfSQ8:
+		return entity.NewIndexIvfFlatSearchParam(s.Nprobe)
+	case IndexTypeHNSW:
+		return entity.NewIndexHNSWSearchParam(s.Ef)
+	case IndexTypeDiskANN:
+		return entity.NewIndexDISKANNSearchParam(s.SearchK)
+	case IndexTypeAutoIndex:
+		return entity.NewIndexAUTOINDEXSearchParam(s.SearchK)
+	default:
+		return entity.NewIndexFlatSearchParam()
+	}
 }
 
 type Milvus struct {
@@ -72,54 +271,336 @@ func (m *Milvus) LoadJSON(ctx context.Context, filename string) error {
 		return err
 	}
 
-	var sections []gptbot.Section
-	if err := json.Unmarshal(data, &sections); err != nil {
+	var docs []Document
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return err
+	}
+
+	return m.Insert(ctx, docs)
+}
+
+// Insert inserts docs, each carrying a dense embedding and, optionally, a
+// sparse embedding for hybrid retrieval (see HybridQuery).
+func (m *Milvus) Insert(ctx context.Context, docs []Document) error {
+	columns, err := m.columnsFromDocs(docs)
+	if err != nil {
 		return err
 	}
 
-	return m.Insert(ctx, sections)
+	_, err = m.client.Insert(ctx, m.cfg.CollectionName, "", columns...)
+	return err
 }
 
-func (m *Milvus) Insert(ctx context.Context, sections []gptbot.Section) error {
-	// We need to release the collection before inserting.
-	if err := m.client.ReleaseCollection(ctx, m.cfg.CollectionName); err != nil {
+// Upsert inserts docs, replacing any existing document that shares the same
+// primary key (Document.ID, or Key hashed to an ID). Unlike Insert, Upsert is
+// safe to call repeatedly with the same documents: it overwrites rather than
+// duplicates rows, so incremental crawlers can simply re-upsert what changed.
+func (m *Milvus) Upsert(ctx context.Context, docs []Document) error {
+	columns, err := m.columnsFromDocs(docs)
+	if err != nil {
 		return err
 	}
 
+	_, err = m.client.Upsert(ctx, m.cfg.CollectionName, "", columns...)
+	return err
+}
+
+// Delete removes the documents with the given primary keys.
+func (m *Milvus) Delete(ctx context.Context, ids []int64) error {
+	return m.client.DeleteByPks(ctx, m.cfg.CollectionName, "", entity.NewColumnInt64(idCol, ids))
+}
+
+// DeleteByExpr removes every document matching the given Milvus boolean
+// expression, e.g. `heading == "draft"`.
+func (m *Milvus) DeleteByExpr(ctx context.Context, expr string) error {
+	return m.client.Delete(ctx, m.cfg.CollectionName, "", expr)
+}
+
+// Count returns the number of rows in the collection. The count may lag
+// behind a recent Insert/Upsert/Delete until Milvus flushes and seals the
+// affected segments.
+func (m *Milvus) Count(ctx context.Context) (int64, error) {
+	stats, err := m.client.GetCollectionStatistics(ctx, m.cfg.CollectionName)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(stats["row_count"], 10, 64)
+}
+
+// columnsFromDocs converts docs into the Milvus columns used by both Insert
+// and Upsert.
+func (m *Milvus) columnsFromDocs(docs []Document) ([]entity.Column, error) {
 	var ids []int64
 	var titles []string
 	var headings []string
 	var contents []string
 	var embeddings [][]float32
-	for i, section := range sections {
-		ids = append(ids, int64(i))
-		titles = append(titles, section.Title)
-		headings = append(headings, section.Heading)
-		contents = append(contents, section.Content)
-		embeddings = append(embeddings, xslices.Float64ToNumber[float32](section.Embedding))
-	}
-
-	idColData := entity.NewColumnInt64(idCol, ids)
-	titleColData := entity.NewColumnVarChar(titleCol, titles)
-	headingColData := entity.NewColumnVarChar(headingCol, headings)
-	contentColData := entity.NewColumnVarChar(contentCol, contents)
-	embeddingColData := entity.NewColumnFloatVector(embeddingCol, m.cfg.Dim, embeddings)
-
-	// Create index "IVF_FLAT".
-	idx, err := entity.NewIndexIvfFlat(entity.L2, 128)
-	if err != nil {
-		return err
+	var sparseEmbeddings []entity.SparseEmbedding
+	var metas [][]byte
+	for i, doc := range docs {
+		ids = append(ids, doc.resolveID(int64(i)))
+		titles = append(titles, doc.Title)
+		headings = append(headings, doc.Heading)
+		contents = append(contents, doc.Content)
+		embeddings = append(embeddings, xslices.Float64ToNumber[float32](doc.Embedding))
+
+		sparseVec, err := toSparseEmbedding(doc.SparseEmbedding)
+		if err != nil {
+			return nil, err
+		}
+		sparseEmbeddings = append(sparseEmbeddings, sparseVec)
+
+		meta, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		metas = append(metas, meta)
 	}
-	if err := m.client.CreateIndex(ctx, m.cfg.CollectionName, embeddingCol, idx, false); err != nil {
-		return err
+
+	return []entity.Column{
+		entity.NewColumnInt64(idCol, ids),
+		entity.NewColumnVarChar(titleCol, titles),
+		entity.NewColumnVarChar(headingCol, headings),
+		entity.NewColumnVarChar(contentCol, contents),
+		entity.NewColumnFloatVector(embeddingCol, m.cfg.Dim, embeddings),
+		entity.NewColumnSparseVectors(sparseEmbeddingCol, sparseEmbeddings),
+		// WithIsDynamic marks this column as the collection's dynamic
+		// field so Milvus stores each row's metadata under its own keys
+		// instead of folding the whole column into "$meta" verbatim.
+		entity.NewColumnJSONBytes(metaCol, metas).WithIsDynamic(true),
+	}, nil
+}
+
+// toSparseEmbedding converts a SparseEmbedding into the position/value
+// representation the Milvus SDK expects, sorted by position.
+func toSparseEmbedding(sparse SparseEmbedding) (entity.SparseEmbedding, error) {
+	positions := make([]uint32, 0, len(sparse))
+	for pos := range sparse {
+		positions = append(positions, pos)
 	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
 
-	_, err = m.client.Insert(ctx, m.cfg.CollectionName, "", idColData, titleColData, headingColData, contentColData, embeddingColData)
-	return err
+	values := make([]float32, len(positions))
+	for i, pos := range positions {
+		values[i] = sparse[pos]
+	}
+
+	return entity.NewSliceSparseEmbedding(positions, values)
+}
+
+// StreamConfig configures InsertStream.
+type StreamConfig struct {
+	// BatchSize is the number of documents buffered before a batch is
+	// flushed. Defaults to 1000.
+	BatchSize int
+
+	// FlushInterval is the longest a partial batch waits before it is
+	// flushed anyway. Defaults to 5s.
+	FlushInterval time.Duration
+
+	// Parallelism is the number of worker goroutines, each holding one
+	// Insert call in flight. Defaults to 1.
+	Parallelism int
+
+	// MaxRetries is the number of times a failed batch is retried, with
+	// exponential backoff, before it is reported as Failed. Defaults to 3.
+	MaxRetries int
+
+	// AutoFlush, if true, flushes the collection and waits for the affected
+	// segments to seal once docs is drained, so callers see fresh data in
+	// their very next Query.
+	AutoFlush bool
+}
+
+func (cfg *StreamConfig) init() {
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.Parallelism == 0 {
+		cfg.Parallelism = 1
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
 }
 
-// Query searches similarities of the given embedding with default consistency level.
-func (m *Milvus) Query(ctx context.Context, embedding gptbot.Embedding, topK int) ([]*gptbot.Similarity, error) {
+// Progress reports InsertStream's cumulative outcome so far.
+type Progress struct {
+	Inserted  int
+	Failed    int
+	LastError error
+}
+
+// InsertStream consumes docs, flushing a batch to Insert every BatchSize
+// documents or every FlushInterval, whichever comes first, and reports
+// cumulative progress on the returned channel. It is meant for corpora too
+// large to build in memory and Insert in a single RPC.
+//
+// Documents without a stable Document.ID or Document.Key are assigned a
+// unique ID drawn from a counter shared across the whole stream, so unlike a
+// plain Insert they never collide across batches.
+//
+// The returned channel is closed once docs is drained, all in-flight
+// batches finish, and (if cfg.AutoFlush) the collection has been flushed.
+func (m *Milvus) InsertStream(ctx context.Context, docs <-chan Document, cfg StreamConfig) (<-chan Progress, error) {
+	cfg.init()
+
+	batches := make(chan []Document)
+	go m.batchDocuments(ctx, docs, batches, cfg)
+
+	progress := make(chan Progress)
+	var mu sync.Mutex
+	var total Progress
+
+	report := func(update func(*Progress)) {
+		mu.Lock()
+		update(&total)
+		snapshot := total
+		mu.Unlock()
+
+		select {
+		case progress <- snapshot:
+		case <-ctx.Done():
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(cfg.Parallelism)
+	for i := 0; i < cfg.Parallelism; i++ {
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				err := m.insertBatchWithRetry(ctx, batch, cfg.MaxRetries)
+
+				report(func(p *Progress) {
+					if err != nil {
+						p.Failed += len(batch)
+						p.LastError = err
+					} else {
+						p.Inserted += len(batch)
+					}
+				})
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		if cfg.AutoFlush {
+			// async=false: the synchronous mode that actually waits for the
+			// affected segments to seal, per the client's own Flush doc
+			// comment. async=true would return before the data is visible
+			// to Query, defeating the point of AutoFlush.
+			if err := m.client.Flush(ctx, m.cfg.CollectionName, false); err != nil {
+				report(func(p *Progress) { p.LastError = err })
+			}
+		}
+		close(progress)
+	}()
+
+	return progress, nil
+}
+
+// autoIDCounter hands out auto-assigned Document IDs to every batchDocuments
+// call in the process. It is seeded once from the current time so IDs also
+// stay out of the small integer range callers are likely to pick by hand,
+// and shared via atomic.AddInt64 so concurrent and successive InsertStream
+// calls in this process never hand out the same value.
+var autoIDCounter = time.Now().UnixNano()
+
+// batchDocuments reads docs and sends batches of up to cfg.BatchSize
+// documents to batches, flushing early if cfg.FlushInterval elapses with a
+// non-empty partial batch. It closes batches once docs is drained or ctx is
+// done.
+//
+// Any document without a stable Document.ID or Document.Key is assigned an
+// ID from autoIDCounter rather than a batch-local index, so unlike a plain
+// Insert, auto-assigned IDs never collide across batches, across concurrent
+// InsertStream calls, or across separate calls in the same process.
+func (m *Milvus) batchDocuments(ctx context.Context, docs <-chan Document, batches chan<- []Document, cfg StreamConfig) {
+	defer close(batches)
+
+	batch := make([]Document, 0, cfg.BatchSize)
+	timer := time.NewTimer(cfg.FlushInterval)
+	defer timer.Stop()
+
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		select {
+		case batches <- batch:
+			batch = make([]Document, 0, cfg.BatchSize)
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case doc, ok := <-docs:
+			if !ok {
+				flush()
+				return
+			}
+
+			if doc.ID == 0 && doc.Key == "" {
+				doc.ID = atomic.AddInt64(&autoIDCounter, 1)
+			}
+			batch = append(batch, doc)
+			if len(batch) >= cfg.BatchSize {
+				if !flush() {
+					return
+				}
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(cfg.FlushInterval)
+			}
+		case <-timer.C:
+			if !flush() {
+				return
+			}
+			timer.Reset(cfg.FlushInterval)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// insertBatchWithRetry calls Insert, retrying on any error up to maxRetries
+// times with exponential backoff starting at 100ms. Errors are not told
+// apart: a permanent error is retried just like a transient one.
+func (m *Milvus) insertBatchWithRetry(ctx context.Context, batch []Document, maxRetries int) error {
+	backoff := 100 * time.Millisecond
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = m.Insert(ctx, batch); err == nil {
+			return nil
+		}
+		if attempt >= maxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Query searches similarities of the given embedding with default consistency
+// level. filter, if non-empty, is a Milvus boolean expression (e.g.
+// `title == "foo" && heading in ["a","b"]`) that restricts the search to
+// matching rows, including rows matched against Document.Metadata.
+func (m *Milvus) Query(ctx context.Context, embedding gptbot.Embedding, topK int, filter string) ([]*Similarity, error) {
 	// We need to load the collection before searching.
 	if err := m.client.LoadCollection(ctx, m.cfg.CollectionName, false); err != nil {
 		return nil, err
@@ -130,16 +611,19 @@ func (m *Milvus) Query(ctx context.Context, embedding gptbot.Embedding, topK int
 		entity.FloatVector(float32Emb),
 	}
 
-	param, _ := entity.NewIndexFlatSearchParam()
+	param, err := m.cfg.Search.buildSearchParam(m.cfg.Index.Type)
+	if err != nil {
+		return nil, err
+	}
 	result, err := m.client.Search(
 		ctx,
 		m.cfg.CollectionName,
 		nil,
-		"",
-		[]string{idCol, titleCol, headingCol, contentCol},
+		filter,
+		[]string{idCol, titleCol, headingCol, contentCol, metaCol},
 		vec2search,
 		embeddingCol,
-		entity.L2,
+		m.cfg.Index.Metric,
 		topK,
 		param,
 	)
@@ -150,6 +634,125 @@ func (m *Milvus) Query(ctx context.Context, embedding gptbot.Embedding, topK int
 	return constructSimilaritiesFromResult(&result[0])
 }
 
+// HybridQuery searches both the dense and sparse columns and fuses the two
+// ranked lists via a weighted linear combination of their min-max normalized
+// scores, controlled by alpha (1 weighs dense only, 0 weighs sparse only).
+// filter is applied to both searches, see Query.
+func (m *Milvus) HybridQuery(ctx context.Context, denseEmb gptbot.Embedding, sparseEmb SparseEmbedding, topK int, alpha float64, filter string) ([]*Similarity, error) {
+	if err := m.client.LoadCollection(ctx, m.cfg.CollectionName, false); err != nil {
+		return nil, err
+	}
+
+	outputFields := []string{idCol, titleCol, headingCol, contentCol, metaCol}
+
+	float32Emb := xslices.Float64ToNumber[float32](denseEmb)
+	denseParam, err := m.cfg.Search.buildSearchParam(m.cfg.Index.Type)
+	if err != nil {
+		return nil, err
+	}
+	denseResult, err := m.client.Search(
+		ctx, m.cfg.CollectionName, nil, filter, outputFields,
+		[]entity.Vector{entity.FloatVector(float32Emb)},
+		embeddingCol, m.cfg.Index.Metric, topK, denseParam,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sparseVec, err := toSparseEmbedding(sparseEmb)
+	if err != nil {
+		return nil, err
+	}
+	sparseParam, _ := entity.NewIndexSparseInvertedSearchParam(0.2)
+	sparseResult, err := m.client.Search(
+		ctx, m.cfg.CollectionName, nil, filter, outputFields,
+		[]entity.Vector{sparseVec},
+		sparseEmbeddingCol, entity.IP, topK, sparseParam,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	denseSims, err := constructSimilaritiesFromResult(&denseResult[0])
+	if err != nil {
+		return nil, err
+	}
+	sparseSims, err := constructSimilaritiesFromResult(&sparseResult[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// L2 scores are distances, so a lower dense score is a better match.
+	return fuseSimilarities(denseSims, sparseSims, alpha, topK, m.cfg.Index.Metric == entity.L2), nil
+}
+
+// fuseSimilarities combines dense and sparse results matched by ID, weighting
+// their per-list min-max normalized scores by alpha, and ranks them by the
+// fused score. If denseLowerIsBetter, dense scores are inverted after
+// normalization so the best dense match gets 1. The returned
+// Similarity.Score is the fused score.
+func fuseSimilarities(dense, sparse []*Similarity, alpha float64, topK int, denseLowerIsBetter bool) []*Similarity {
+	normalize := func(sims []*Similarity, lowerIsBetter bool) map[int]float64 {
+		if len(sims) == 0 {
+			return nil
+		}
+		min, max := sims[0].Score, sims[0].Score
+		for _, s := range sims {
+			min = math.Min(min, s.Score)
+			max = math.Max(max, s.Score)
+		}
+		scores := make(map[int]float64, len(sims))
+		for _, s := range sims {
+			if max == min {
+				scores[s.ID] = 1
+				continue
+			}
+			norm := (s.Score - min) / (max - min)
+			if lowerIsBetter {
+				norm = 1 - norm
+			}
+			scores[s.ID] = norm
+		}
+		return scores
+	}
+
+	denseScores := normalize(dense, denseLowerIsBetter)
+	sparseScores := normalize(sparse, false)
+
+	rawDenseScores := make(map[int]float64, len(dense))
+	for _, s := range dense {
+		rawDenseScores[s.ID] = s.Score
+	}
+	rawSparseScores := make(map[int]float64, len(sparse))
+	for _, s := range sparse {
+		rawSparseScores[s.ID] = s.Score
+	}
+
+	byID := make(map[int]*Similarity)
+	order := make([]int, 0, len(dense)+len(sparse))
+	for _, s := range append(append([]*Similarity{}, dense...), sparse...) {
+		if _, ok := byID[s.ID]; !ok {
+			byID[s.ID] = s
+			order = append(order, s.ID)
+		}
+	}
+
+	fused := make([]*Similarity, 0, len(order))
+	for _, id := range order {
+		sim := *byID[id]
+		sim.DenseScore = rawDenseScores[id]
+		sim.SparseScore = rawSparseScores[id]
+		sim.Score = alpha*denseScores[id] + (1-alpha)*sparseScores[id]
+		fused = append(fused, &sim)
+	}
+	sort.Slice(fused, func(i, j int) bool { return fused[i].Score > fused[j].Score })
+
+	if len(fused) > topK {
+		fused = fused[:topK]
+	}
+	return fused
+}
+
 func (m *Milvus) createCollectionIfNotExists(ctx context.Context) error {
 	has, err := m.client.HasCollection(ctx, m.cfg.CollectionName)
 	if err != nil {
@@ -166,6 +769,10 @@ func (m *Milvus) createCollectionIfNotExists(ctx context.Context) error {
 	schema := &entity.Schema{
 		CollectionName: m.cfg.CollectionName,
 		AutoID:         false,
+		// EnableDynamicField lets Insert attach arbitrary Document.Metadata
+		// to each row via the reserved "$meta" JSON column, without
+		// declaring a dedicated schema field per metadata key.
+		EnableDynamicField: true,
 		Fields: []*entity.Field{
 			{
 				Name:       idCol,
@@ -200,18 +807,41 @@ func (m *Milvus) createCollectionIfNotExists(ctx context.Context) error {
 					entity.TypeParamDim: fmt.Sprintf("%d", m.cfg.Dim),
 				},
 			},
+			{
+				Name:     sparseEmbeddingCol,
+				DataType: entity.FieldTypeSparseVector,
+			},
 		},
 	}
 
 	// Create collection with consistency level, which serves as the default search/query consistency level.
-	return m.client.CreateCollection(ctx, schema, 2, client.WithConsistencyLevel(entity.ClBounded))
+	if err := m.client.CreateCollection(ctx, schema, 2, client.WithConsistencyLevel(entity.ClBounded)); err != nil {
+		return err
+	}
+
+	// Build indexes once at collection creation time, rather than on every
+	// Insert/Upsert.
+	idx, err := m.cfg.Index.buildIndex()
+	if err != nil {
+		return err
+	}
+	if err := m.client.CreateIndex(ctx, m.cfg.CollectionName, embeddingCol, idx, false); err != nil {
+		return err
+	}
+
+	sparseIdx, err := entity.NewIndexSparseInverted(entity.IP, 0.2)
+	if err != nil {
+		return err
+	}
+	return m.client.CreateIndex(ctx, m.cfg.CollectionName, sparseEmbeddingCol, sparseIdx, false)
 }
 
-func constructSimilaritiesFromResult(result *client.SearchResult) ([]*gptbot.Similarity, error) {
+func constructSimilaritiesFromResult(result *client.SearchResult) ([]*Similarity, error) {
 	var iCol *entity.ColumnInt64
 	var tCol *entity.ColumnVarChar
 	var hCol *entity.ColumnVarChar
 	var cCol *entity.ColumnVarChar
+	var mCol *entity.ColumnJSONBytes
 	for _, field := range result.Fields {
 		switch field.Name() {
 		case idCol:
@@ -230,10 +860,14 @@ func constructSimilaritiesFromResult(result *client.SearchResult) ([]*gptbot.Sim
 			if c, ok := field.(*entity.ColumnVarChar); ok {
 				cCol = c
 			}
+		case metaCol:
+			if c, ok := field.(*entity.ColumnJSONBytes); ok {
+				mCol = c
+			}
 		}
 	}
 
-	var similarities []*gptbot.Similarity
+	var similarities []*Similarity
 	for i := 0; i < result.ResultCount; i++ {
 		iVal, err := iCol.ValueByIdx(i)
 		if err != nil {
@@ -252,14 +886,30 @@ func constructSimilaritiesFromResult(result *client.SearchResult) ([]*gptbot.Sim
 			return nil, err
 		}
 
-		similarities = append(similarities, &gptbot.Similarity{
-			Section: gptbot.Section{
-				Title:   tVal,
-				Heading: hVal,
-				Content: cVal,
+		var metadata map[string]any
+		if mCol != nil {
+			mVal, err := mCol.ValueByIdx(i)
+			if err != nil {
+				return nil, err
+			}
+			if len(mVal) > 0 {
+				if err := json.Unmarshal(mVal, &metadata); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		similarities = append(similarities, &Similarity{
+			Similarity: gptbot.Similarity{
+				Section: gptbot.Section{
+					Title:   tVal,
+					Heading: hVal,
+					Content: cVal,
+				},
+				ID:    int(iVal),
+				Score: float64(result.Scores[i]),
 			},
-			ID:    int(iVal),
-			Score: float64(result.Scores[i]),
+			Metadata: metadata,
 		})
 	}
 
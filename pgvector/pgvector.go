@@ -0,0 +1,342 @@
+// Package pgvector implements gptbot's vector store contract on top of
+// PostgreSQL with the pgvector extension, as a lightweight alternative to
+// the milvus package for users who don't want to run a Milvus deployment.
+package pgvector
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-aie/gptbot"
+	"github.com/lib/pq"
+)
+
+// Distance selects the pgvector distance operator used to order Query
+// results.
+type Distance string
+
+const (
+	DistanceL2        Distance = "<->"
+	DistanceCosine    Distance = "<=>"
+	DistanceInnerProd Distance = "<#>"
+)
+
+// IndexType selects the pgvector index type built on the embedding column.
+type IndexType string
+
+const (
+	IndexTypeIVFFlat IndexType = "ivfflat"
+	IndexTypeHNSW    IndexType = "hnsw"
+)
+
+// IndexConfig selects the index type and index-specific build parameters
+// used for the embedding column.
+type IndexConfig struct {
+	// Type is the index algorithm. Defaults to IndexTypeIVFFlat.
+	Type IndexType
+
+	// Lists is the number of inverted lists, used by IVFFlat. Defaults to 100.
+	Lists int
+
+	// M is the number of bi-directional links per node, used by HNSW.
+	// Defaults to 16.
+	M int
+
+	// EfConstruction controls the HNSW build-time search scope, used by
+	// HNSW. Defaults to 64.
+	EfConstruction int
+}
+
+func (idx *IndexConfig) init() {
+	if idx.Type == "" {
+		idx.Type = IndexTypeIVFFlat
+	}
+	if idx.Lists == 0 {
+		idx.Lists = 100
+	}
+	if idx.M == 0 {
+		idx.M = 16
+	}
+	if idx.EfConstruction == 0 {
+		idx.EfConstruction = 64
+	}
+}
+
+type Config struct {
+	// DSN is the PostgreSQL connection string.
+	// This field is required.
+	DSN string
+
+	// TableName is the table used to store sections.
+	// Defaults to "gptbot_sections".
+	TableName string
+
+	// Dim is the embedding dimension.
+	// Defaults to 1536 (the dimension generated by OpenAI's Embedding API).
+	Dim int
+
+	// Distance is the distance operator used to order Query results.
+	// Defaults to DistanceCosine, since OpenAI embeddings are normalized.
+	Distance Distance
+
+	// Index configures the index built on the embedding column.
+	Index IndexConfig
+
+	// Filter is an optional boolean expression used as the WHERE clause of
+	// every Query, e.g. "metadata->>'tenant' = 'acme'". It lets
+	// callers scope retrieval by metadata without passing a filter on every
+	// call.
+	Filter string
+}
+
+func (cfg *Config) init() {
+	if cfg.TableName == "" {
+		cfg.TableName = "gptbot_sections"
+	}
+	if cfg.Dim == 0 {
+		cfg.Dim = 1536
+	}
+	if cfg.Distance == "" {
+		cfg.Distance = DistanceCosine
+	}
+	cfg.Index.init()
+}
+
+// Document bundles a gptbot.Section together with arbitrary metadata stored
+// in the table's jsonb metadata column.
+type Document struct {
+	gptbot.Section
+	Metadata map[string]any
+}
+
+// Similarity extends gptbot.Similarity with the Metadata attached at
+// insertion time.
+type Similarity struct {
+	gptbot.Similarity
+	Metadata map[string]any
+}
+
+type PgVector struct {
+	db  *sql.DB
+	cfg *Config
+}
+
+func NewPgVector(cfg *Config) (*PgVector, error) {
+	cfg.init()
+
+	db, err := sql.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	p := &PgVector{
+		db:  db,
+		cfg: cfg,
+	}
+
+	ctx := context.Background()
+	if err := p.createSchemaIfNotExists(ctx); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *PgVector) createSchemaIfNotExists(ctx context.Context) error {
+	if _, err := p.db.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
+		return err
+	}
+
+	createTable := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id bigserial PRIMARY KEY,
+		title text,
+		heading text,
+		content text,
+		embedding vector(%d),
+		metadata jsonb
+	)`, pq.QuoteIdentifier(p.cfg.TableName), p.cfg.Dim)
+	if _, err := p.db.ExecContext(ctx, createTable); err != nil {
+		return err
+	}
+
+	return p.createIndexIfNotExists(ctx)
+}
+
+func (p *PgVector) createIndexIfNotExists(ctx context.Context) error {
+	idxName := pq.QuoteIdentifier(p.cfg.TableName + "_embedding_idx")
+	table := pq.QuoteIdentifier(p.cfg.TableName)
+
+	var opClass string
+	switch p.cfg.Distance {
+	case DistanceL2:
+		opClass = "vector_l2_ops"
+	case DistanceInnerProd:
+		opClass = "vector_ip_ops"
+	default:
+		opClass = "vector_cosine_ops"
+	}
+
+	var with string
+	switch p.cfg.Index.Type {
+	case IndexTypeHNSW:
+		with = fmt.Sprintf("(m = %d, ef_construction = %d)", p.cfg.Index.M, p.cfg.Index.EfConstruction)
+	default:
+		with = fmt.Sprintf("(lists = %d)", p.cfg.Index.Lists)
+	}
+
+	createIndex := fmt.Sprintf(
+		`CREATE INDEX IF NOT EXISTS %s ON %s USING %s (embedding %s) WITH %s`,
+		idxName, table, p.cfg.Index.Type, opClass, with,
+	)
+	_, err := p.db.ExecContext(ctx, createIndex)
+	return err
+}
+
+func (p *PgVector) LoadJSON(ctx context.Context, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var docs []Document
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return err
+	}
+
+	return p.Insert(ctx, docs)
+}
+
+// maxInsertBatchRows caps the number of documents bound into a single
+// Insert statement. Each row binds 5 parameters, and PostgreSQL rejects any
+// statement with more than 65535 bound parameters, so this keeps every
+// batch (10000 * 5 = 50000) comfortably under that limit.
+const maxInsertBatchRows = 10000
+
+// Insert batches docs into the table via multi-VALUES statements, which is
+// friendlier to pgvector-backed tables than one round trip per row. Larger
+// doc slices are split into sub-batches of maxInsertBatchRows to stay under
+// PostgreSQL's bound-parameter limit; all sub-batches run in a single
+// transaction so a failure partway through leaves no rows committed.
+func (p *PgVector) Insert(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for len(docs) > 0 {
+		n := maxInsertBatchRows
+		if n > len(docs) {
+			n = len(docs)
+		}
+		if err := p.insertBatch(ctx, tx, docs[:n]); err != nil {
+			return err
+		}
+		docs = docs[n:]
+	}
+
+	return tx.Commit()
+}
+
+func (p *PgVector) insertBatch(ctx context.Context, tx *sql.Tx, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	var placeholders []string
+	args := make([]any, 0, len(docs)*5)
+	for i, doc := range docs {
+		metadata, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return err
+		}
+
+		base := i * 5
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4, base+5))
+		args = append(args, doc.Title, doc.Heading, doc.Content, encodeVector(doc.Embedding), metadata)
+	}
+
+	stmt := fmt.Sprintf(
+		`INSERT INTO %s (title, heading, content, embedding, metadata) VALUES %s`,
+		pq.QuoteIdentifier(p.cfg.TableName), strings.Join(placeholders, ", "),
+	)
+	_, err := tx.ExecContext(ctx, stmt, args...)
+	return err
+}
+
+// Query searches similarities of the given embedding, ordered by the
+// configured Distance operator.
+func (p *PgVector) Query(ctx context.Context, embedding gptbot.Embedding, topK int) ([]*Similarity, error) {
+	where := ""
+	if p.cfg.Filter != "" {
+		where = "WHERE " + p.cfg.Filter
+	}
+
+	q := fmt.Sprintf(
+		`SELECT id, title, heading, content, metadata, embedding %s $1 AS score
+		 FROM %s %s
+		 ORDER BY score
+		 LIMIT $2`,
+		p.cfg.Distance, pq.QuoteIdentifier(p.cfg.TableName), where,
+	)
+
+	rows, err := p.db.QueryContext(ctx, q, encodeVector(embedding), topK)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var similarities []*Similarity
+	for rows.Next() {
+		var id int64
+		var title, heading, content string
+		var metadataBytes []byte
+		var score float64
+		if err := rows.Scan(&id, &title, &heading, &content, &metadataBytes, &score); err != nil {
+			return nil, err
+		}
+
+		var metadata map[string]any
+		if len(metadataBytes) > 0 {
+			if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
+				return nil, err
+			}
+		}
+
+		similarities = append(similarities, &Similarity{
+			Similarity: gptbot.Similarity{
+				Section: gptbot.Section{
+					Title:   title,
+					Heading: heading,
+					Content: content,
+				},
+				ID:    int(id),
+				Score: score,
+			},
+			Metadata: metadata,
+		})
+	}
+
+	return similarities, rows.Err()
+}
+
+// encodeVector renders embedding in the text format pgvector expects, e.g.
+// "[0.1,0.2,0.3]".
+func encodeVector(embedding gptbot.Embedding) string {
+	parts := make([]string, len(embedding))
+	for i, v := range embedding {
+		parts[i] = fmt.Sprintf("%g", v)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}